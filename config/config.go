@@ -38,12 +38,36 @@
 //  cnf.DIY(key string) (interface{}, error)
 //  cnf.GetSection(section string) (map[string]string, error)
 //  cnf.SaveConfigFile(filename string) error
+//  cnf.Reload() error
+//  cnf.Unmarshal(target interface{}) error
+//  cnf.UnmarshalKey(key string, target interface{}) error
 //
+//  values written as ENC(...) are decrypted transparently when
+//  NewConfig/NewConfigData is called with config.WithDecryptor("aes-gcm")
+//
+//  cnf, err := config.ParseWithIncludes("ini", "config.conf") resolves an
+//  `include = a.conf, conf/*.conf` directive (or `$include` in json/yaml)
+//  at the top of config.conf, merging every included file underneath it
+//
+
 //  more docs http://beego.me/docs/module/config.md
 package config
 
 import (
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/rand"
+	"encoding/base64"
+	"flag"
 	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
 )
 
 // Configer defines how to get and set value from configuration raw data.
@@ -72,6 +96,12 @@ type Configer interface {
 	DIY(key string) (interface{}, error)
 	GetSection(section string) (map[string]string, error)
 	SaveConfigFile(filename string) error
+	//重新解析底层数据源，原子地替换内存中的配置数据，不影响正在进行的读操作。
+	Reload() error
+	//将配置数据绑定到 target 指向的结构体，字段通过 config/default/required tag 声明。
+	Unmarshal(target interface{}) error
+	//将 key 对应的子配置数据（section）绑定到 target 指向的结构体。
+	UnmarshalKey(key string, target interface{}) error
 }
 
 // Config is the adapter interface for parsing config file to get raw data to Configer.
@@ -105,8 +135,11 @@ func Register(name string, adapter Config) {
 
 // NewConfig adapterName is ini/json/xml/yaml.
 // filename is the config file path.
+// opts can be used to e.g. pass WithDecryptor so values written as
+// ENC(...) are transparently decrypted on read.
 // 通过解析器解析配置文件，获得配置文件操作对象。当前可解析 ini/json/xml/yaml 格式文件。
-func NewConfig(adapterName, filename string) (Configer, error) {
+// opts 可用于传入如 WithDecryptor，使 ENC(...) 包裹的值在读取时被透明解密。
+func NewConfig(adapterName, filename string, opts ...ConfigOption) (Configer, error) {
 
 	//提取解析器，如果解析器不存在，则返回错误信息。
 	adapter, ok := adapters[adapterName]
@@ -114,13 +147,17 @@ func NewConfig(adapterName, filename string) (Configer, error) {
 		return nil, fmt.Errorf("config: unknown adaptername %q (forgotten import?)", adapterName)
 	}
 	//对应解析器，解析配置文件。
-	return adapter.Parse(filename)
+	cnf, err := adapter.Parse(filename)
+	if err != nil {
+		return nil, err
+	}
+	return applyOptions(cnf, opts)
 }
 
 // NewConfigData adapterName is ini/json/xml/yaml.
 // data is the config data.
 // 指定解析器直接解析配置数据，返回数配置数据操作对象。
-func NewConfigData(adapterName string, data []byte) (Configer, error) {
+func NewConfigData(adapterName string, data []byte, opts ...ConfigOption) (Configer, error) {
 
 	//提取解析器，如果解析器不存在，则返回错误信息。
 	adapter, ok := adapters[adapterName]
@@ -128,5 +165,1241 @@ func NewConfigData(adapterName string, data []byte) (Configer, error) {
 		return nil, fmt.Errorf("config: unknown adaptername %q (forgotten import?)", adapterName)
 	}
 	//对应解析器，解析配置数据。
-	return adapter.ParseData(data)
+	cnf, err := adapter.ParseData(data)
+	if err != nil {
+		return nil, err
+	}
+	return applyOptions(cnf, opts)
+}
+
+// IncludeDirective is the ini key (and json/yaml equivalent IncludeKey)
+// adapters honor to pull in other config files, e.g.
+// `include = conf/db.conf, conf/log/*.conf` at the top of an ini file.
+// 适配器用于引入其它配置文件的 ini key（json/yaml 对应 IncludeKey），例如在 ini
+// 文件顶部写 `include = conf/db.conf, conf/log/*.conf`。
+const IncludeDirective = "include"
+
+// IncludeKey is the json/yaml key equivalent of IncludeDirective.
+// json/yaml 中与 IncludeDirective 对应的 key。
+const IncludeKey = "$include"
+
+// ErrCircularInclude is returned by ExpandIncludes when an include chain
+// revisits a file it has already started parsing.
+// 当 include 链路重新访问了一个已经在解析中的文件时，ExpandIncludes 返回该错误。
+type ErrCircularInclude struct {
+	Cycle []string
+}
+
+func (e *ErrCircularInclude) Error() string {
+	return fmt.Sprintf("config: circular include detected: %s", strings.Join(e.Cycle, " -> "))
+}
+
+// ExpandIncludes resolves the comma-separated list of file paths/globs
+// found in an include directive's value (IncludeDirective in ini,
+// IncludeKey in json/yaml), relative to the directory of baseFile, and
+// returns every matched file in the order the patterns were listed.
+//
+// chain tracks the files currently being expanded, keyed by absolute path
+// with each value recording its position in the traversal (0 for the first
+// file opened, 1 for the next, and so on), and must be shared across one
+// whole ParseWithIncludes call so cycles are detected as it recurses
+// into included files: ExpandIncludes adds baseFile to chain before
+// resolving its patterns, and returns an *ErrCircularInclude - listing the
+// cycle in the order the files were actually included - if baseFile or any
+// file its patterns resolve to is already on chain. The returned done func
+// removes baseFile from chain again once the caller has finished recursing
+// into its includes (typically via defer), so a diamond include graph - two
+// files that both include the same shared file - is not mistaken for a
+// cycle.
+//
+// ExpandIncludes only resolves *which* files an include directive refers
+// to; ParseWithIncludes parses those files with the including file's adapter
+// and merges them into the including Configer.
+// 解析 include 指令取值中以逗号分隔的路径/glob 列表，相对 baseFile 所在目录
+// 展开，按模式声明顺序返回所有匹配到的文件。
+//
+// chain 记录当前正在展开的文件链，键为绝对路径，值为其在展开顺序中的位置
+// （第一个打开的文件为 0，依次递增），需要在一次完整的 ParseWithIncludes 调用
+// 中共享，以便在递归解析被引入的文件时检测循环引用：ExpandIncludes 会
+// 在解析 baseFile 的模式之前将其加入 chain，若 baseFile 本身或其模式解析出的
+// 任意文件已在链上，则返回 *ErrCircularInclude，按实际引入顺序列出循环路径。
+// 返回的 done 函数需在调用方递归完 baseFile 的 include 后调用（通常用
+// defer），将 baseFile 从 chain 中移除，这样两个文件共同引入同一个公共文件
+// （菱形引用）就不会被误判为循环。
+//
+// ExpandIncludes 只负责解出 include 指令指向的文件列表，由 ParseWithIncludes
+// 使用引入方文件的适配器解析这些文件并合并进调用方 Configer。
+func ExpandIncludes(baseFile, value string, chain map[string]int) (files []string, done func(), err error) {
+	abs, err := filepath.Abs(baseFile)
+	if err != nil {
+		return nil, nil, err
+	}
+	if _, ok := chain[abs]; ok {
+		return nil, nil, circularIncludeError(chain, abs)
+	}
+	chain[abs] = len(chain)
+	done = func() { delete(chain, abs) }
+
+	dir := filepath.Dir(abs)
+	for _, pattern := range strings.Split(value, ",") {
+		pattern = strings.TrimSpace(pattern)
+		if pattern == "" {
+			continue
+		}
+		if !filepath.IsAbs(pattern) {
+			pattern = filepath.Join(dir, pattern)
+		}
+		matches, globErr := filepath.Glob(pattern)
+		if globErr != nil {
+			done()
+			return nil, nil, globErr
+		}
+		if len(matches) == 0 {
+			done()
+			return nil, nil, fmt.Errorf("config: include pattern %q matched no files", pattern)
+		}
+		for _, m := range matches {
+			if _, ok := chain[m]; ok {
+				cycleErr := circularIncludeError(chain, m)
+				done()
+				return nil, nil, cycleErr
+			}
+		}
+		files = append(files, matches...)
+	}
+	return files, done, nil
+}
+
+// circularIncludeError builds the *ErrCircularInclude reported when target
+// (either baseFile itself, or a file one of its patterns resolved to) is
+// already on chain, listing the cycle in the order the files were actually
+// included rather than alphabetically.
+// 构建当 target（baseFile 自身，或其某个模式解析出的文件）已在 chain 中时
+// 返回的 *ErrCircularInclude，按实际引入顺序而非字母序列出循环路径。
+func circularIncludeError(chain map[string]int, target string) *ErrCircularInclude {
+	cycle := make([]string, len(chain))
+	for p, i := range chain {
+		cycle[i] = p
+	}
+	return &ErrCircularInclude{Cycle: append(cycle[chain[target]:], target)}
+}
+
+// ParseWithIncludes parses filename with the named adapter, same as
+// NewConfig, and additionally honors an include directive found in the
+// parsed result: IncludeDirective (ini's `include = a.conf, conf/*.conf`)
+// or, if that is unset, IncludeKey (json/yaml's `$include`). Every included
+// file is parsed with the same adapter and recursively expanded the same
+// way, then layered under filename via NewMergedConfig, so keys set
+// directly in filename win over its includes, and circular includes are
+// rejected with an *ErrCircularInclude. Adapters themselves stay unaware of
+// includes; this is the generic entry point that makes the directive work
+// for any adapter registered via Register.
+// 用指定适配器解析 filename（与 NewConfig 相同），并额外处理解析结果中的
+// include 指令：IncludeDirective（ini 的 `include = a.conf, conf/*.conf`），
+// 若未设置则看 IncludeKey（json/yaml 的 `$include`）。每个被引入的文件都用同一
+// 适配器解析并递归展开，再通过 NewMergedConfig 叠在 filename 之下，因此
+// filename 中直接设置的 key 会覆盖其 include 进来的值，循环引用会返回
+// *ErrCircularInclude。适配器本身无需感知 include；这是让该指令对任何通过
+// Register 注册的适配器都生效的通用入口。
+func ParseWithIncludes(adapterName, filename string, opts ...ConfigOption) (Configer, error) {
+	return parseWithIncludes(adapterName, filename, make(map[string]int), opts)
+}
+
+func parseWithIncludes(adapterName, filename string, chain map[string]int, opts []ConfigOption) (Configer, error) {
+	cnf, err := NewConfig(adapterName, filename, opts...)
+	if err != nil {
+		return nil, err
+	}
+
+	raw := cnf.String(IncludeDirective)
+	if raw == "" {
+		raw = cnf.String(IncludeKey)
+	}
+	if raw == "" {
+		return cnf, nil
+	}
+
+	files, done, err := ExpandIncludes(filename, raw, chain)
+	if err != nil {
+		return nil, err
+	}
+	defer done()
+
+	layers := make([]Configer, 0, len(files)+1)
+	for _, f := range files {
+		included, err := parseWithIncludes(adapterName, f, chain, opts)
+		if err != nil {
+			return nil, err
+		}
+		layers = append(layers, included)
+	}
+	layers = append(layers, cnf)
+	return NewMergedConfig(layers...)
+}
+
+// ConfigEvent describes a single change pushed by a remote configuration
+// backend. Value is empty and Deleted is true when the key was removed.
+// 远程配置变更事件，Deleted 为 true 时表示该 Key 已被删除，Value 此时为空。
+type ConfigEvent struct {
+	Key     string
+	Value   string
+	Deleted bool
+}
+
+// RemoteProvider is implemented by packages that know how to talk to a
+// specific remote key/value store, e.g. etcd, Consul or ZooKeeper.
+// 远程配置后端的驱动接口，etcd/Consul/ZooKeeper 等第三方实现均需满足该接口。
+type RemoteProvider interface {
+	// Fetch returns every key under path as a flat section::key map, using
+	// the same section::key convention as the ini adapter.
+	Fetch(endpoint, path string) (map[string]string, error)
+	// Watch blocks until stop is closed, pushing a ConfigEvent to out every
+	// time key changes on the backend. Watch must return when stop is
+	// closed.
+	Watch(endpoint, path, key string, out chan<- ConfigEvent, stop <-chan struct{}) error
+}
+
+// 已注册远程配置驱动池。
+var remoteProviders = make(map[string]RemoteProvider)
+
+// RegisterRemote makes a RemoteProvider available by name, e.g. "etcd",
+// "consul" or "zookeeper". If RegisterRemote is called twice with the same
+// name, or if provider is nil, it panics.
+// 注册远程配置驱动，重复注册或驱动为 nil 时 panic，用法与 Register 一致。
+func RegisterRemote(name string, provider RemoteProvider) {
+	if provider == nil {
+		panic("config: RegisterRemote provider is nil")
+	}
+	if _, ok := remoteProviders[name]; ok {
+		panic("config: RegisterRemote called twice for provider " + name)
+	}
+	remoteProviders[name] = provider
+}
+
+// watchDebounce is how long Watch waits after the latest remote change event
+// on a key before applying it and forwarding it; an event that arrives
+// sooner replaces the pending one and restarts the wait.
+const watchDebounce = 100 * time.Millisecond
+
+// kvConfiger is a Configer implementation backed by a flat, in-memory
+// section::key map. It is embedded by adapters that do not parse their own
+// file format but instead build their data from another source (remote
+// backends, environment variables, flags, ...).
+// 基于内存 map 的 Configer 基础实现，供不需要自行解析文件格式、而是从其它来源
+// （远程后端、环境变量、命令行参数等）构建数据的适配器内嵌复用。
+type kvConfiger struct {
+	lock sync.RWMutex
+	data map[string]string
+}
+
+func (c *kvConfiger) snapshot() map[string]string {
+	c.lock.RLock()
+	defer c.lock.RUnlock()
+	m := make(map[string]string, len(c.data))
+	for k, v := range c.data {
+		m[k] = v
+	}
+	return m
+}
+
+func (c *kvConfiger) replace(data map[string]string) {
+	if data == nil {
+		data = make(map[string]string)
+	}
+	c.lock.Lock()
+	c.data = data
+	c.lock.Unlock()
+}
+
+// Reload is a no-op for a plain kvConfiger: its data only ever changes via
+// replace, called by whatever embeds it (envConfig re-scans the
+// environment, flagConfig re-scans its FlagSet, remoteConfig re-fetches).
+// Embedders that need real reload behavior override this method.
+// 对普通 kvConfiger 而言 Reload 是空操作：其数据只通过 replace 变化，由内嵌者
+// 负责调用（envConfig 重新扫描环境变量、flagConfig 重新扫描 FlagSet、
+// remoteConfig 重新拉取）；需要真正重新加载行为的内嵌者会覆盖此方法。
+func (c *kvConfiger) Reload() error {
+	return nil
+}
+
+// defaultString, and its siblings below, implement the fallback-on-miss
+// logic shared by every Default* method on every Configer in this file
+// (kvConfiger, decryptingConfiger, mergedConfig), parameterized over that
+// type's own String/Strings/Int/... so the logic lives in exactly one place.
+func defaultString(get func(string) string, key, defaultVal string) string {
+	if v := get(key); v != "" {
+		return v
+	}
+	return defaultVal
+}
+
+func defaultStrings(get func(string) []string, key string, defaultVal []string) []string {
+	if v := get(key); v != nil {
+		return v
+	}
+	return defaultVal
+}
+
+func defaultInt(get func(string) (int, error), key string, defaultVal int) int {
+	if v, err := get(key); err == nil {
+		return v
+	}
+	return defaultVal
+}
+
+func defaultInt64(get func(string) (int64, error), key string, defaultVal int64) int64 {
+	if v, err := get(key); err == nil {
+		return v
+	}
+	return defaultVal
+}
+
+func defaultBool(get func(string) (bool, error), key string, defaultVal bool) bool {
+	if v, err := get(key); err == nil {
+		return v
+	}
+	return defaultVal
+}
+
+func defaultFloat(get func(string) (float64, error), key string, defaultVal float64) float64 {
+	if v, err := get(key); err == nil {
+		return v
+	}
+	return defaultVal
+}
+
+func (c *kvConfiger) Set(key, val string) error {
+	c.lock.Lock()
+	defer c.lock.Unlock()
+	c.data[key] = val
+	return nil
+}
+
+func (c *kvConfiger) String(key string) string {
+	c.lock.RLock()
+	defer c.lock.RUnlock()
+	return c.data[key]
+}
+
+func (c *kvConfiger) Strings(key string) []string {
+	v := c.String(key)
+	if v == "" {
+		return nil
+	}
+	return strings.Split(v, ";")
+}
+
+func (c *kvConfiger) Int(key string) (int, error) {
+	return strconv.Atoi(c.String(key))
+}
+
+func (c *kvConfiger) Int64(key string) (int64, error) {
+	return strconv.ParseInt(c.String(key), 10, 64)
+}
+
+func (c *kvConfiger) Bool(key string) (bool, error) {
+	return strconv.ParseBool(c.String(key))
+}
+
+func (c *kvConfiger) Float(key string) (float64, error) {
+	return strconv.ParseFloat(c.String(key), 64)
+}
+
+func (c *kvConfiger) DefaultString(key string, defaultVal string) string {
+	return defaultString(c.String, key, defaultVal)
+}
+
+func (c *kvConfiger) DefaultStrings(key string, defaultVal []string) []string {
+	return defaultStrings(c.Strings, key, defaultVal)
+}
+
+func (c *kvConfiger) DefaultInt(key string, defaultVal int) int {
+	return defaultInt(c.Int, key, defaultVal)
+}
+
+func (c *kvConfiger) DefaultInt64(key string, defaultVal int64) int64 {
+	return defaultInt64(c.Int64, key, defaultVal)
+}
+
+func (c *kvConfiger) DefaultBool(key string, defaultVal bool) bool {
+	return defaultBool(c.Bool, key, defaultVal)
+}
+
+func (c *kvConfiger) DefaultFloat(key string, defaultVal float64) float64 {
+	return defaultFloat(c.Float, key, defaultVal)
+}
+
+func (c *kvConfiger) DIY(key string) (interface{}, error) {
+	c.lock.RLock()
+	defer c.lock.RUnlock()
+	if v, ok := c.data[key]; ok {
+		return v, nil
+	}
+	return nil, fmt.Errorf("config: key %q not found", key)
+}
+
+func (c *kvConfiger) GetSection(section string) (map[string]string, error) {
+	c.lock.RLock()
+	defer c.lock.RUnlock()
+	prefix := section + "::"
+	res := make(map[string]string)
+	for k, v := range c.data {
+		if strings.HasPrefix(k, prefix) {
+			res[strings.TrimPrefix(k, prefix)] = v
+		}
+	}
+	if len(res) == 0 {
+		return nil, fmt.Errorf("config: section %q not found", section)
+	}
+	return res, nil
+}
+
+func (c *kvConfiger) SaveConfigFile(filename string) error {
+	return fmt.Errorf("config: SaveConfigFile is not supported by this adapter")
+}
+
+func (c *kvConfiger) Unmarshal(target interface{}) error {
+	return unmarshal(c, "", target)
+}
+
+func (c *kvConfiger) UnmarshalKey(key string, target interface{}) error {
+	return unmarshal(c, key, target)
+}
+
+// Validator is implemented by an Unmarshal/UnmarshalKey target that needs to
+// run cross-field validation once every tagged field has been populated.
+// 实现该接口的 target 会在所有 tag 字段绑定完成后被调用，用于跨字段校验。
+type Validator interface {
+	Validate() error
+}
+
+// UnmarshalError aggregates every missing or invalid key found while
+// binding config data to a struct via Unmarshal/UnmarshalKey, so callers
+// see the full list instead of only the first failure.
+// Unmarshal/UnmarshalKey 绑定过程中遇到的缺失/非法 key 会全部收集在此，而非
+// 遇到第一个错误就返回，便于一次性修正所有问题。
+type UnmarshalError struct {
+	Errs []error
+}
+
+func (e *UnmarshalError) Error() string {
+	msgs := make([]string, len(e.Errs))
+	for i, err := range e.Errs {
+		msgs[i] = err.Error()
+	}
+	return fmt.Sprintf("config: %d error(s) binding struct: %s", len(e.Errs), strings.Join(msgs, "; "))
+}
+
+// unmarshal binds data read from c, at the given key prefix, into target
+// following the struct tags on target's fields: `config:"name"` names the
+// key (the field name when absent, "-" skips the field), `default:"..."`
+// is used when the key is unset, and `required:"true"` reports an unset key
+// with no default as an error. Struct fields bind to the nested section.
+func unmarshal(c Configer, prefix string, target interface{}) error {
+	rv := reflect.ValueOf(target)
+	if rv.Kind() != reflect.Ptr || rv.IsNil() || rv.Elem().Kind() != reflect.Struct {
+		return fmt.Errorf("config: Unmarshal target must be a non-nil struct pointer")
+	}
+	var errs []error
+	bindStruct(c, prefix, rv.Elem(), &errs)
+	if len(errs) > 0 {
+		return &UnmarshalError{Errs: errs}
+	}
+	if v, ok := target.(Validator); ok {
+		return v.Validate()
+	}
+	return nil
+}
+
+// durationType lets bindStruct special-case time.Duration fields, which are
+// otherwise indistinguishable from a plain int64 via reflection.
+var durationType = reflect.TypeOf(time.Duration(0))
+
+func bindStruct(c Configer, prefix string, rv reflect.Value, errs *[]error) {
+	rt := rv.Type()
+	for i := 0; i < rt.NumField(); i++ {
+		field := rt.Field(i)
+		fv := rv.Field(i)
+		if field.PkgPath != "" { // unexported field
+			continue
+		}
+		name := field.Tag.Get("config")
+		if name == "-" {
+			continue
+		}
+		if name == "" {
+			name = field.Name
+		}
+		key := name
+		if prefix != "" {
+			key = prefix + "::" + name
+		}
+
+		if fv.Kind() == reflect.Struct {
+			// time.Duration is an int64 underneath, so it never reaches
+			// here; any genuine struct field maps to a nested section.
+			bindStruct(c, key, fv, errs)
+			continue
+		}
+
+		required := field.Tag.Get("required") == "true"
+		def, hasDef := field.Tag.Lookup("default")
+
+		if fv.Kind() == reflect.Slice {
+			vals := c.Strings(key)
+			if len(vals) == 0 {
+				switch {
+				case hasDef:
+					vals = strings.Split(def, ";")
+				case required:
+					*errs = append(*errs, fmt.Errorf("missing required key %q", key))
+					continue
+				default:
+					continue
+				}
+			}
+			slice := reflect.MakeSlice(fv.Type(), len(vals), len(vals))
+			for j, v := range vals {
+				slice.Index(j).SetString(expandEnv(v))
+			}
+			fv.Set(slice)
+			continue
+		}
+
+		raw := c.String(key)
+		switch {
+		case raw != "":
+		case hasDef:
+			raw = def
+		case required:
+			*errs = append(*errs, fmt.Errorf("missing required key %q", key))
+			continue
+		default:
+			continue
+		}
+
+		if err := setField(fv, expandEnv(raw)); err != nil {
+			*errs = append(*errs, fmt.Errorf("invalid value for key %q: %v", key, err))
+		}
+	}
+}
+
+// expandEnv expands ${VAR} references in raw using the process environment,
+// leaving the text untouched when it contains none.
+func expandEnv(raw string) string {
+	if !strings.Contains(raw, "${") {
+		return raw
+	}
+	return os.Expand(raw, os.Getenv)
+}
+
+func setField(fv reflect.Value, raw string) error {
+	if fv.Type() == durationType {
+		d, err := time.ParseDuration(raw)
+		if err != nil {
+			return err
+		}
+		fv.SetInt(int64(d))
+		return nil
+	}
+	switch fv.Kind() {
+	case reflect.String:
+		fv.SetString(raw)
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		n, err := strconv.ParseInt(raw, 10, 64)
+		if err != nil {
+			return err
+		}
+		fv.SetInt(n)
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+		n, err := strconv.ParseUint(raw, 10, 64)
+		if err != nil {
+			return err
+		}
+		fv.SetUint(n)
+	case reflect.Bool:
+		b, err := strconv.ParseBool(raw)
+		if err != nil {
+			return err
+		}
+		fv.SetBool(b)
+	case reflect.Float32, reflect.Float64:
+		f, err := strconv.ParseFloat(raw, 64)
+		if err != nil {
+			return err
+		}
+		fv.SetFloat(f)
+	default:
+		return fmt.Errorf("unsupported field kind %s", fv.Kind())
+	}
+	return nil
+}
+
+// Decryptor decrypts and re-encrypts values found wrapped in the
+// ENC(base64ciphertext) marker, so secrets never need to sit in plaintext
+// inside an ini/json/yaml/xml config file on disk.
+// 对 ENC(base64ciphertext) 包裹的值进行解密/重新加密，使配置文件中的敏感信息
+// 不必以明文落盘。
+type Decryptor interface {
+	Decrypt(ciphertext string) (string, error)
+	Encrypt(plaintext string) (string, error)
+}
+
+// 已注册解密器实例池。
+var decryptors = make(map[string]Decryptor)
+
+// RegisterDecryptor makes a Decryptor available by name, so it can be
+// selected via WithDecryptor. If RegisterDecryptor is called twice with the
+// same name, or if d is nil, it panics.
+// 注册解密器，重复注册或解密器为 nil 时 panic，用法与 Register 一致。
+func RegisterDecryptor(name string, d Decryptor) {
+	if d == nil {
+		panic("config: RegisterDecryptor decryptor is nil")
+	}
+	if _, ok := decryptors[name]; ok {
+		panic("config: RegisterDecryptor called twice for decryptor " + name)
+	}
+	decryptors[name] = d
+}
+
+const (
+	encPrefix = "ENC("
+	encSuffix = ")"
+)
+
+// isEncrypted reports whether val carries the ENC(...) marker, returning
+// the base64 ciphertext it wraps.
+func isEncrypted(val string) (string, bool) {
+	if strings.HasPrefix(val, encPrefix) && strings.HasSuffix(val, encSuffix) {
+		return val[len(encPrefix) : len(val)-len(encSuffix)], true
+	}
+	return "", false
+}
+
+func wrapEncrypted(ciphertext string) string {
+	return encPrefix + ciphertext + encSuffix
+}
+
+// ConfigOption configures a Configer built by NewConfig/NewConfigData.
+type ConfigOption func(*configOptions)
+
+type configOptions struct {
+	decryptorName string
+}
+
+// WithDecryptor selects, by name, the Decryptor (registered via
+// RegisterDecryptor) used to transparently decrypt ENC(...) values read
+// through String/Int/DIY/etc., and to re-encrypt values SaveConfigFile
+// writes back out.
+// 按名称选择通过 RegisterDecryptor 注册的解密器，用于在 String/Int/DIY 等读取
+// 路径上透明解密 ENC(...) 值，并在 SaveConfigFile 写回时重新加密。
+func WithDecryptor(name string) ConfigOption {
+	return func(o *configOptions) {
+		o.decryptorName = name
+	}
+}
+
+func applyOptions(cnf Configer, opts []ConfigOption) (Configer, error) {
+	var o configOptions
+	for _, opt := range opts {
+		opt(&o)
+	}
+	if o.decryptorName == "" {
+		return cnf, nil
+	}
+	d, ok := decryptors[o.decryptorName]
+	if !ok {
+		return nil, fmt.Errorf("config: unknown decryptor %q (forgotten import?)", o.decryptorName)
+	}
+	return &decryptingConfiger{Configer: cnf, d: d}, nil
+}
+
+// decryptingConfiger wraps a Configer and makes ENC(...) values transparent
+// to callers: reads are decrypted on the way out, and Set re-encrypts a new
+// value under the same key if the value it replaces was encrypted, so the
+// ENC(...) marker survives a later SaveConfigFile.
+// 包裹任意 Configer，使 ENC(...) 值对调用方透明：读取时自动解密；若 Set 覆盖的
+// 是曾经加密的 key，则对新值重新加密，从而在之后的 SaveConfigFile 中保留
+// ENC(...) 标记，不会把明文落盘。
+type decryptingConfiger struct {
+	Configer
+	d Decryptor
+}
+
+func (c *decryptingConfiger) decrypt(val string) string {
+	ct, ok := isEncrypted(val)
+	if !ok {
+		return val
+	}
+	pt, err := c.d.Decrypt(ct)
+	if err != nil {
+		// Hand back the value with its ENC(...) marker still in place, so a
+		// misconfigured key shows up as a wrapped value (and makes Int/Bool/
+		// Float fail to parse) rather than as bare ciphertext.
+		return val
+	}
+	return pt
+}
+
+func (c *decryptingConfiger) String(key string) string {
+	return c.decrypt(c.Configer.String(key))
+}
+
+func (c *decryptingConfiger) Strings(key string) []string {
+	v := c.String(key)
+	if v == "" {
+		return nil
+	}
+	return strings.Split(v, ";")
+}
+
+func (c *decryptingConfiger) Int(key string) (int, error) {
+	return strconv.Atoi(c.String(key))
+}
+
+func (c *decryptingConfiger) Int64(key string) (int64, error) {
+	return strconv.ParseInt(c.String(key), 10, 64)
+}
+
+func (c *decryptingConfiger) Bool(key string) (bool, error) {
+	return strconv.ParseBool(c.String(key))
+}
+
+func (c *decryptingConfiger) Float(key string) (float64, error) {
+	return strconv.ParseFloat(c.String(key), 64)
+}
+
+func (c *decryptingConfiger) DefaultString(key string, defaultVal string) string {
+	return defaultString(c.String, key, defaultVal)
+}
+
+func (c *decryptingConfiger) DefaultStrings(key string, defaultVal []string) []string {
+	return defaultStrings(c.Strings, key, defaultVal)
+}
+
+func (c *decryptingConfiger) DefaultInt(key string, defaultVal int) int {
+	return defaultInt(c.Int, key, defaultVal)
+}
+
+func (c *decryptingConfiger) DefaultInt64(key string, defaultVal int64) int64 {
+	return defaultInt64(c.Int64, key, defaultVal)
+}
+
+func (c *decryptingConfiger) DefaultBool(key string, defaultVal bool) bool {
+	return defaultBool(c.Bool, key, defaultVal)
+}
+
+func (c *decryptingConfiger) DefaultFloat(key string, defaultVal float64) float64 {
+	return defaultFloat(c.Float, key, defaultVal)
+}
+
+func (c *decryptingConfiger) DIY(key string) (interface{}, error) {
+	v, err := c.Configer.DIY(key)
+	if err != nil {
+		return v, err
+	}
+	if s, ok := v.(string); ok {
+		return c.decrypt(s), nil
+	}
+	return v, nil
+}
+
+func (c *decryptingConfiger) GetSection(section string) (map[string]string, error) {
+	m, err := c.Configer.GetSection(section)
+	if err != nil {
+		return nil, err
+	}
+	out := make(map[string]string, len(m))
+	for k, v := range m {
+		out[k] = c.decrypt(v)
+	}
+	return out, nil
+}
+
+func (c *decryptingConfiger) Set(key, val string) error {
+	// Re-encrypt so a later SaveConfigFile on the wrapped Configer keeps
+	// writing ENC(...), never the plaintext val passed in here.
+	if _, ok := isEncrypted(c.Configer.String(key)); ok {
+		ct, err := c.d.Encrypt(val)
+		if err != nil {
+			return err
+		}
+		val = wrapEncrypted(ct)
+	}
+	return c.Configer.Set(key, val)
+}
+
+func (c *decryptingConfiger) Unmarshal(target interface{}) error {
+	return unmarshal(c, "", target)
+}
+
+func (c *decryptingConfiger) UnmarshalKey(key string, target interface{}) error {
+	return unmarshal(c, key, target)
+}
+
+// aesGCMDecryptor is the default Decryptor, registered under "aes-gcm". It
+// sources its 256-bit key from the BEEGO_CONFIG_KEY env var (base64) and
+// stores a 12-byte GCM nonce as a prefix on the ciphertext, so the whole
+// ENC(...) payload is a single base64 string.
+// 默认解密器，以 "aes-gcm" 注册；密钥取自 BEEGO_CONFIG_KEY 环境变量
+// （base64 编码的 32 字节），密文前缀 12 字节 GCM nonce，整体 base64 编码后
+// 作为 ENC(...) 的内容。
+type aesGCMDecryptor struct{}
+
+func init() {
+	RegisterDecryptor("aes-gcm", aesGCMDecryptor{})
+}
+
+func (aesGCMDecryptor) gcm() (cipher.AEAD, error) {
+	raw := os.Getenv("BEEGO_CONFIG_KEY")
+	if raw == "" {
+		return nil, fmt.Errorf("config: BEEGO_CONFIG_KEY is not set")
+	}
+	key, err := base64.StdEncoding.DecodeString(raw)
+	if err != nil {
+		return nil, fmt.Errorf("config: invalid BEEGO_CONFIG_KEY: %v", err)
+	}
+	if len(key) != 32 {
+		return nil, fmt.Errorf("config: BEEGO_CONFIG_KEY must decode to 32 bytes for AES-256, got %d", len(key))
+	}
+	block, err := aes.NewCipher(key)
+	if err != nil {
+		return nil, err
+	}
+	return cipher.NewGCM(block)
+}
+
+func (a aesGCMDecryptor) Decrypt(ciphertext string) (string, error) {
+	gcm, err := a.gcm()
+	if err != nil {
+		return "", err
+	}
+	raw, err := base64.StdEncoding.DecodeString(ciphertext)
+	if err != nil {
+		return "", fmt.Errorf("config: invalid ENC(...) payload: %v", err)
+	}
+	if len(raw) < gcm.NonceSize() {
+		return "", fmt.Errorf("config: ENC(...) payload shorter than the GCM nonce")
+	}
+	nonce, ct := raw[:gcm.NonceSize()], raw[gcm.NonceSize():]
+	pt, err := gcm.Open(nil, nonce, ct, nil)
+	if err != nil {
+		return "", err
+	}
+	return string(pt), nil
+}
+
+func (a aesGCMDecryptor) Encrypt(plaintext string) (string, error) {
+	gcm, err := a.gcm()
+	if err != nil {
+		return "", err
+	}
+	nonce := make([]byte, gcm.NonceSize())
+	if _, err := io.ReadFull(rand.Reader, nonce); err != nil {
+		return "", err
+	}
+	ct := gcm.Seal(nonce, nonce, []byte(plaintext), nil)
+	return base64.StdEncoding.EncodeToString(ct), nil
+}
+
+// mergedConfig is a Configer that layers several Configer sources into a
+// single view, where later sources in the slice take priority over earlier
+// ones on lookups; GetSection unions keys across every layer instead.
+// 由多个 Configer 分层组成的 Configer 视图，slice 中靠后的来源在查找时优先级
+// 更高；GetSection 则是对所有层的 key 取并集。
+type mergedConfig struct {
+	layers []Configer
+}
+
+// NewMergedConfig composes sources into a single Configer where later
+// sources override earlier ones, e.g. defaults loaded from a YAML file,
+// then environment-specific overrides from an ini file, then NewEnvConfig
+// or NewFlagConfig for last-mile, twelve-factor style overrides.
+// 将多个来源组合为单一 Configer，靠后的来源覆盖靠前的来源，典型用法是：YAML
+// 默认配置 -> 环境相关的 ini 覆盖 -> NewEnvConfig/NewFlagConfig 做最后一层
+// （十二要素风格）覆盖。
+func NewMergedConfig(sources ...Configer) (Configer, error) {
+	if len(sources) == 0 {
+		return nil, fmt.Errorf("config: NewMergedConfig requires at least one source")
+	}
+	layers := make([]Configer, len(sources))
+	copy(layers, sources)
+	return &mergedConfig{layers: layers}, nil
+}
+
+func (m *mergedConfig) Set(key, val string) error {
+	var lastErr error
+	for i := len(m.layers) - 1; i >= 0; i-- {
+		if err := m.layers[i].Set(key, val); err == nil {
+			return nil
+		} else {
+			lastErr = err
+		}
+	}
+	return lastErr
+}
+
+func (m *mergedConfig) String(key string) string {
+	for i := len(m.layers) - 1; i >= 0; i-- {
+		if v := m.layers[i].String(key); v != "" {
+			return v
+		}
+	}
+	return ""
+}
+
+func (m *mergedConfig) Strings(key string) []string {
+	for i := len(m.layers) - 1; i >= 0; i-- {
+		if v := m.layers[i].Strings(key); v != nil {
+			return v
+		}
+	}
+	return nil
+}
+
+func (m *mergedConfig) Int(key string) (int, error) {
+	return strconv.Atoi(m.String(key))
+}
+
+func (m *mergedConfig) Int64(key string) (int64, error) {
+	return strconv.ParseInt(m.String(key), 10, 64)
+}
+
+func (m *mergedConfig) Bool(key string) (bool, error) {
+	return strconv.ParseBool(m.String(key))
+}
+
+func (m *mergedConfig) Float(key string) (float64, error) {
+	return strconv.ParseFloat(m.String(key), 64)
+}
+
+func (m *mergedConfig) DefaultString(key string, defaultVal string) string {
+	return defaultString(m.String, key, defaultVal)
+}
+
+func (m *mergedConfig) DefaultStrings(key string, defaultVal []string) []string {
+	return defaultStrings(m.Strings, key, defaultVal)
+}
+
+func (m *mergedConfig) DefaultInt(key string, defaultVal int) int {
+	return defaultInt(m.Int, key, defaultVal)
+}
+
+func (m *mergedConfig) DefaultInt64(key string, defaultVal int64) int64 {
+	return defaultInt64(m.Int64, key, defaultVal)
+}
+
+func (m *mergedConfig) DefaultBool(key string, defaultVal bool) bool {
+	return defaultBool(m.Bool, key, defaultVal)
+}
+
+func (m *mergedConfig) DefaultFloat(key string, defaultVal float64) float64 {
+	return defaultFloat(m.Float, key, defaultVal)
+}
+
+func (m *mergedConfig) DIY(key string) (interface{}, error) {
+	var lastErr error
+	for i := len(m.layers) - 1; i >= 0; i-- {
+		v, err := m.layers[i].DIY(key)
+		if err == nil {
+			return v, nil
+		}
+		lastErr = err
+	}
+	if lastErr == nil {
+		lastErr = fmt.Errorf("config: key %q not found", key)
+	}
+	return nil, lastErr
+}
+
+func (m *mergedConfig) GetSection(section string) (map[string]string, error) {
+	out := make(map[string]string)
+	found := false
+	for _, layer := range m.layers {
+		sec, err := layer.GetSection(section)
+		if err != nil {
+			continue
+		}
+		found = true
+		for k, v := range sec {
+			out[k] = v
+		}
+	}
+	if !found {
+		return nil, fmt.Errorf("config: section %q not found", section)
+	}
+	return out, nil
+}
+
+func (m *mergedConfig) SaveConfigFile(filename string) error {
+	return fmt.Errorf("config: SaveConfigFile is not supported on a merged config; call it on an individual layer instead")
+}
+
+// Reload reloads every layer in place, collecting every failure instead of
+// stopping at the first one.
+// 原地重新加载每一层，收集所有失败而非在第一个错误处中断。
+func (m *mergedConfig) Reload() error {
+	var msgs []string
+	for _, layer := range m.layers {
+		if err := layer.Reload(); err != nil {
+			msgs = append(msgs, err.Error())
+		}
+	}
+	if len(msgs) > 0 {
+		return fmt.Errorf("config: %d layer(s) failed to reload: %s", len(msgs), strings.Join(msgs, "; "))
+	}
+	return nil
+}
+
+func (m *mergedConfig) Unmarshal(target interface{}) error {
+	return unmarshal(m, "", target)
+}
+
+func (m *mergedConfig) UnmarshalKey(key string, target interface{}) error {
+	return unmarshal(m, key, target)
+}
+
+// envConfig is a Configer that exposes process environment variables
+// matching prefix through the Configer API. PREFIX_HTTPPORT becomes the key
+// "httpport"; a double underscore marks a section boundary, so
+// PREFIX_MYSQL__HOST becomes "mysql::host", matching the ini adapter's
+// section::key convention.
+// 将匹配 prefix 的环境变量以 Configer API 暴露；PREFIX_HTTPPORT 对应 key
+// "httpport"，双下划线表示分节，PREFIX_MYSQL__HOST 对应 "mysql::host"，与 ini
+// 解析器的 section::key 约定保持一致。
+type envConfig struct {
+	kvConfiger
+	prefix string
+}
+
+// NewEnvConfig exposes BEEGO_HTTPPORT style environment variables through
+// the Configer API; see envConfig for the PREFIX_SECTION__KEY convention.
+// 通过 Configer API 暴露形如 BEEGO_HTTPPORT 的环境变量，命名约定见 envConfig。
+func NewEnvConfig(prefix string) Configer {
+	e := &envConfig{prefix: prefix}
+	e.replace(loadEnv(prefix))
+	return e
+}
+
+func loadEnv(prefix string) map[string]string {
+	p := prefix
+	if p != "" && !strings.HasSuffix(p, "_") {
+		p += "_"
+	}
+	data := make(map[string]string)
+	for _, kv := range os.Environ() {
+		parts := strings.SplitN(kv, "=", 2)
+		if len(parts) != 2 {
+			continue
+		}
+		k, v := parts[0], parts[1]
+		if p != "" {
+			if !strings.HasPrefix(k, p) {
+				continue
+			}
+			k = strings.TrimPrefix(k, p)
+		}
+		k = strings.Replace(strings.ToLower(k), "__", "::", -1)
+		data[k] = v
+	}
+	return data
+}
+
+// Reload re-reads os.Environ(), so a process-level env change (e.g. in
+// tests) is picked up without rebuilding the Configer.
+// 重新读取 os.Environ()，使进程级的环境变量变化（如测试中）无需重建 Configer
+// 即可生效。
+func (e *envConfig) Reload() error {
+	e.replace(loadEnv(e.prefix))
+	return nil
+}
+
+// flagConfig is a Configer that exposes a flag.FlagSet's current values
+// through the Configer API, keyed by flag name.
+// 将 flag.FlagSet 当前的值以 flag 名作为 key，通过 Configer API 暴露。
+type flagConfig struct {
+	kvConfiger
+	fs *flag.FlagSet
+}
+
+// NewFlagConfig adapts fs so its flags are readable through the Configer
+// API, typically as the top, last-mile layer of a NewMergedConfig chain.
+// 将 fs 适配为 Configer，常作为 NewMergedConfig 链条中最后一层（优先级最高）
+// 使用。
+func NewFlagConfig(fs *flag.FlagSet) Configer {
+	f := &flagConfig{fs: fs}
+	f.replace(loadFlags(fs))
+	return f
+}
+
+func loadFlags(fs *flag.FlagSet) map[string]string {
+	data := make(map[string]string)
+	fs.VisitAll(func(fl *flag.Flag) {
+		data[fl.Name] = fl.Value.String()
+	})
+	return data
+}
+
+// Reload re-reads the current value of every flag in fs.
+// 重新读取 fs 中每个 flag 的当前值。
+func (f *flagConfig) Reload() error {
+	f.replace(loadFlags(f.fs))
+	return nil
+}
+
+// remoteConfig is a Configer/RemoteConfig implementation that fetches its
+// data from a RemoteProvider and keeps it fresh via Watch.
+// 基于 RemoteProvider 拉取数据的 Configer 实现，并通过 Watch 保持数据新鲜。
+type remoteConfig struct {
+	kvConfiger
+	provider       RemoteProvider
+	endpoint, path string
+
+	watchMu sync.Mutex
+	stops   []chan struct{}
+}
+
+// RemoteConfig is implemented by Configer instances backed by a remote
+// key/value store. It augments Configer with change notification so callers
+// can react to configuration pushed from etcd, Consul, ZooKeeper, etc.
+// 远程配置专用接口，在 Configer 基础上增加变更通知能力。
+type RemoteConfig interface {
+	Configer
+	// Watch returns a channel that receives a ConfigEvent every time key
+	// changes on the remote backend. The underlying storage is swapped
+	// under a RWMutex before the event is delivered, so String/Int/etc.
+	// observed after receiving from the channel are already up to date.
+	Watch(key string) (<-chan ConfigEvent, error)
+	// Close stops every goroutine started by Watch and closes their
+	// channels. It is safe to call more than once.
+	Close() error
+}
+
+// NewRemoteConfig builds a Configer whose data is fetched from the named
+// remote provider (as registered via RegisterRemote) at endpoint/path.
+// providerName is e.g. "etcd", "consul" or "zookeeper".
+// 通过已注册的远程配置驱动拉取 endpoint/path 下的数据，构建 Configer。
+func NewRemoteConfig(providerName, endpoint, path string) (Configer, error) {
+	provider, ok := remoteProviders[providerName]
+	if !ok {
+		return nil, fmt.Errorf("config: unknown remote provider %q (forgotten import?)", providerName)
+	}
+	data, err := provider.Fetch(endpoint, path)
+	if err != nil {
+		return nil, err
+	}
+	return NewRemoteConfigData(providerName, endpoint, path, data)
+}
+
+// NewRemoteConfigData builds a Configer for the named remote provider using
+// a caller-supplied snapshot instead of fetching one, so the initial values
+// are available immediately. It is mainly useful when the snapshot was
+// already retrieved elsewhere (e.g. bundled with application startup) and
+// the caller only wants NewRemoteConfig's Watch/Reload behavior on top.
+// 使用调用方已拉取的初始数据快照构建远程 Configer，跳过首次 Fetch，仅复用其
+// Watch/Reload 能力，常用于启动阶段已经获得快照的场景。
+func NewRemoteConfigData(providerName, endpoint, path string, data map[string]string) (Configer, error) {
+	provider, ok := remoteProviders[providerName]
+	if !ok {
+		return nil, fmt.Errorf("config: unknown remote provider %q (forgotten import?)", providerName)
+	}
+	rc := &remoteConfig{
+		provider: provider,
+		endpoint: endpoint,
+		path:     path,
+	}
+	rc.replace(data)
+	return rc, nil
+}
+
+// Reload re-fetches the full data set from the remote backend and swaps it
+// in atomically under a write lock, leaving concurrent readers unaffected.
+// 重新从远程后端拉取全量数据并在写锁下原子替换，不影响正在进行的读取。
+func (c *remoteConfig) Reload() error {
+	data, err := c.provider.Fetch(c.endpoint, c.path)
+	if err != nil {
+		return err
+	}
+	c.replace(data)
+	return nil
+}
+
+// Watch starts a background goroutine that listens for change events on key
+// from the remote backend, debounces bursts of updates, swaps the affected
+// value under the write lock and forwards a ConfigEvent to the returned
+// channel. The goroutine runs until Close is called or the provider's Watch
+// returns, and the returned channel is closed when it stops.
+// 启动后台 goroutine 监听 key 在远程后端的变更，合并（防抖）突发更新，写锁下
+// 替换受影响的值，并将事件转发到返回的 channel；该 goroutine 会持续运行直至
+// 调用 Close 或驱动的 Watch 返回，停止时关闭返回的 channel。
+func (c *remoteConfig) Watch(key string) (<-chan ConfigEvent, error) {
+	raw := make(chan ConfigEvent)
+	out := make(chan ConfigEvent)
+	stop := make(chan struct{})
+
+	c.watchMu.Lock()
+	c.stops = append(c.stops, stop)
+	c.watchMu.Unlock()
+
+	go func() {
+		defer close(raw)
+		if err := c.provider.Watch(c.endpoint, c.path, key, raw, stop); err != nil {
+			return
+		}
+	}()
+
+	go func() {
+		defer close(out)
+		var pending *ConfigEvent
+		timer := time.NewTimer(0)
+		if !timer.Stop() {
+			<-timer.C
+		}
+		for {
+			select {
+			case <-stop:
+				return
+			case ev, ok := <-raw:
+				if !ok {
+					return
+				}
+				e := ev
+				pending = &e
+				timer.Reset(watchDebounce)
+			case <-timer.C:
+				if pending == nil {
+					continue
+				}
+				ev := *pending
+				pending = nil
+				c.lock.Lock()
+				if ev.Deleted {
+					delete(c.data, ev.Key)
+				} else {
+					c.data[ev.Key] = ev.Value
+				}
+				c.lock.Unlock()
+				select {
+				case out <- ev:
+				case <-stop:
+					return
+				}
+			}
+		}
+	}()
+
+	return out, nil
+}
+
+// Close stops every goroutine started by Watch and closes their channels.
+// It is safe to call more than once.
+// 停止所有由 Watch 启动的 goroutine 并关闭其 channel，可重复调用。
+func (c *remoteConfig) Close() error {
+	c.watchMu.Lock()
+	defer c.watchMu.Unlock()
+	for _, stop := range c.stops {
+		close(stop)
+	}
+	c.stops = nil
+	return nil
 }
@@ -0,0 +1,521 @@
+// Copyright 2014 beego Author. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package config
+
+import (
+	"encoding/base64"
+	"flag"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+)
+
+func newKV(data map[string]string) *kvConfiger {
+	c := &kvConfiger{}
+	c.replace(data)
+	return c
+}
+
+func TestKVConfigerDefaults(t *testing.T) {
+	c := newKV(map[string]string{"foo": "bar"})
+	if v := c.DefaultString("foo", "x"); v != "bar" {
+		t.Errorf("DefaultString(foo) = %q, want bar", v)
+	}
+	if v := c.DefaultString("missing", "x"); v != "x" {
+		t.Errorf("DefaultString(missing) = %q, want x", v)
+	}
+	if v := c.DefaultInt("missing", 42); v != 42 {
+		t.Errorf("DefaultInt(missing) = %d, want 42", v)
+	}
+}
+
+func TestAESGCMRoundTrip(t *testing.T) {
+	os.Setenv("BEEGO_CONFIG_KEY", base64.StdEncoding.EncodeToString(make([]byte, 32)))
+	defer os.Unsetenv("BEEGO_CONFIG_KEY")
+
+	d := aesGCMDecryptor{}
+	ct, err := d.Encrypt("s3cr3t")
+	if err != nil {
+		t.Fatalf("Encrypt: %v", err)
+	}
+	pt, err := d.Decrypt(ct)
+	if err != nil {
+		t.Fatalf("Decrypt: %v", err)
+	}
+	if pt != "s3cr3t" {
+		t.Errorf("round trip = %q, want s3cr3t", pt)
+	}
+}
+
+func TestDecryptingConfigerTransparentDecrypt(t *testing.T) {
+	os.Setenv("BEEGO_CONFIG_KEY", base64.StdEncoding.EncodeToString(make([]byte, 32)))
+	defer os.Unsetenv("BEEGO_CONFIG_KEY")
+
+	d := decryptors["aes-gcm"]
+	ct, err := d.Encrypt("hunter2")
+	if err != nil {
+		t.Fatalf("Encrypt: %v", err)
+	}
+
+	inner := newKV(map[string]string{
+		"db::password": wrapEncrypted(ct),
+		"db::host":     "localhost",
+	})
+	dc := &decryptingConfiger{Configer: inner, d: d}
+
+	if got := dc.String("db::password"); got != "hunter2" {
+		t.Errorf("String(db::password) = %q, want hunter2", got)
+	}
+	if got := dc.String("db::host"); got != "localhost" {
+		t.Errorf("String(db::host) = %q, want localhost (unwrapped values pass through)", got)
+	}
+
+	if err := dc.Set("db::password", "newpass"); err != nil {
+		t.Fatalf("Set: %v", err)
+	}
+	raw := inner.String("db::password")
+	if _, ok := isEncrypted(raw); !ok {
+		t.Errorf("after Set, underlying value is %q, want it still ENC(...)-wrapped", raw)
+	}
+	if got := dc.String("db::password"); got != "newpass" {
+		t.Errorf("String(db::password) after Set = %q, want newpass", got)
+	}
+}
+
+type dbConfig struct {
+	Host string `config:"host"`
+	Port int    `config:"port" default:"3306"`
+}
+
+type appConfig struct {
+	HTTPPort int           `config:"httpport" required:"true"`
+	Timeout  time.Duration `config:"timeout" default:"5s"`
+	Tags     []string      `config:"tags"`
+	DataDir  string        `config:"datadir" default:"${TESTHOME}/data"`
+	Mysql    dbConfig      `config:"mysql"`
+}
+
+func (c *appConfig) Validate() error {
+	if c.HTTPPort <= 0 {
+		return fmt.Errorf("httpport must be positive")
+	}
+	return nil
+}
+
+func TestUnmarshal(t *testing.T) {
+	os.Setenv("TESTHOME", "/home/test")
+	defer os.Unsetenv("TESTHOME")
+
+	c := newKV(map[string]string{
+		"httpport":    "9090",
+		"tags":        "a;b;c",
+		"mysql::host": "db.local",
+	})
+	var cfg appConfig
+	if err := c.Unmarshal(&cfg); err != nil {
+		t.Fatalf("Unmarshal: %v", err)
+	}
+	if cfg.HTTPPort != 9090 {
+		t.Errorf("HTTPPort = %d, want 9090", cfg.HTTPPort)
+	}
+	if cfg.Timeout != 5*time.Second {
+		t.Errorf("Timeout = %v, want 5s (from default tag)", cfg.Timeout)
+	}
+	if !reflect.DeepEqual(cfg.Tags, []string{"a", "b", "c"}) {
+		t.Errorf("Tags = %v, want [a b c]", cfg.Tags)
+	}
+	if cfg.DataDir != "/home/test/data" {
+		t.Errorf("DataDir = %q, want /home/test/data (env expansion)", cfg.DataDir)
+	}
+	if cfg.Mysql.Host != "db.local" {
+		t.Errorf("Mysql.Host = %q, want db.local (nested section)", cfg.Mysql.Host)
+	}
+	if cfg.Mysql.Port != 3306 {
+		t.Errorf("Mysql.Port = %d, want 3306 (default tag)", cfg.Mysql.Port)
+	}
+}
+
+func TestUnmarshalMissingRequiredAggregatesErrors(t *testing.T) {
+	type twoRequired struct {
+		A string `config:"a" required:"true"`
+		B string `config:"b" required:"true"`
+	}
+	c := newKV(map[string]string{})
+	var cfg twoRequired
+	err := c.Unmarshal(&cfg)
+	if err == nil {
+		t.Fatal("want error for missing required keys")
+	}
+	uerr, ok := err.(*UnmarshalError)
+	if !ok {
+		t.Fatalf("want *UnmarshalError, got %T: %v", err, err)
+	}
+	if len(uerr.Errs) != 2 {
+		t.Errorf("want 2 aggregated errors (one per missing key), got %d: %v", len(uerr.Errs), uerr.Errs)
+	}
+}
+
+func TestUnmarshalKeyBindsSection(t *testing.T) {
+	c := newKV(map[string]string{"mysql::host": "db.local", "mysql::port": "3307"})
+	var db dbConfig
+	if err := c.UnmarshalKey("mysql", &db); err != nil {
+		t.Fatalf("UnmarshalKey: %v", err)
+	}
+	if db.Host != "db.local" || db.Port != 3307 {
+		t.Errorf("db = %+v, want {db.local 3307}", db)
+	}
+}
+
+func TestExpandIncludesGlobAndDiamond(t *testing.T) {
+	dir, err := ioutil.TempDir("", "beego-config-test")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	for _, name := range []string{"common.conf", "a.conf", "b.conf", "main.conf"} {
+		if err := ioutil.WriteFile(filepath.Join(dir, name), []byte(""), 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	chain := make(map[string]int)
+	files, done, err := ExpandIncludes(filepath.Join(dir, "main.conf"), "a.conf, b.conf", chain)
+	if err != nil {
+		t.Fatalf("ExpandIncludes(main): %v", err)
+	}
+	defer done()
+	if len(files) != 2 {
+		t.Fatalf("want 2 files, got %d: %v", len(files), files)
+	}
+
+	// a.conf and b.conf both include common.conf: a diamond, not a cycle.
+	_, aDone, err := ExpandIncludes(filepath.Join(dir, "a.conf"), "common.conf", chain)
+	if err != nil {
+		t.Fatalf("ExpandIncludes(a): %v", err)
+	}
+	aDone()
+
+	bFiles, bDone, err := ExpandIncludes(filepath.Join(dir, "b.conf"), "common.conf", chain)
+	if err != nil {
+		t.Fatalf("diamond include wrongly rejected as a cycle: %v", err)
+	}
+	defer bDone()
+	if len(bFiles) != 1 {
+		t.Fatalf("want 1 file, got %d", len(bFiles))
+	}
+}
+
+func TestExpandIncludesCircular(t *testing.T) {
+	dir, err := ioutil.TempDir("", "beego-config-test")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	for _, name := range []string{"a.conf", "b.conf"} {
+		if err := ioutil.WriteFile(filepath.Join(dir, name), []byte(""), 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	// a.conf stays on chain while its own include ("b.conf") is resolved, so
+	// b.conf including a.conf back must be rejected as soon as a.conf shows
+	// up among b.conf's resolved targets - it must not wait for something to
+	// separately recurse into a.conf again.
+	chain := make(map[string]int)
+	_, doneA, err := ExpandIncludes(filepath.Join(dir, "a.conf"), "b.conf", chain)
+	if err != nil {
+		t.Fatalf("ExpandIncludes(a): %v", err)
+	}
+	defer doneA()
+
+	_, _, err = ExpandIncludes(filepath.Join(dir, "b.conf"), "a.conf", chain)
+	if err == nil {
+		t.Fatal("want *ErrCircularInclude, got nil")
+	}
+	cerr, ok := err.(*ErrCircularInclude)
+	if !ok {
+		t.Fatalf("want *ErrCircularInclude, got %T: %v", err, err)
+	}
+	wantCycle := []string{filepath.Join(dir, "a.conf"), filepath.Join(dir, "b.conf"), filepath.Join(dir, "a.conf")}
+	if !reflect.DeepEqual(cerr.Cycle, wantCycle) {
+		t.Errorf("Cycle = %v, want %v in traversal order", cerr.Cycle, wantCycle)
+	}
+}
+
+func TestExpandIncludesNoMatch(t *testing.T) {
+	dir, err := ioutil.TempDir("", "beego-config-test")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	main := filepath.Join(dir, "main.conf")
+	if err := ioutil.WriteFile(main, []byte(""), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	chain := make(map[string]int)
+	if _, _, err := ExpandIncludes(main, "missing-*.conf", chain); err == nil {
+		t.Fatal("want error when an include pattern matches no files")
+	}
+}
+
+// lineAdapter is a minimal Config adapter used only by TestParseWithIncludes
+// and TestParseWithIncludesCircular to exercise ParseWithIncludes end to end
+// without depending on a real
+// ini/json/yaml adapter, none of which exist in this tree.
+type lineAdapter struct{}
+
+func (lineAdapter) Parse(filename string) (Configer, error) {
+	data, err := ioutil.ReadFile(filename)
+	if err != nil {
+		return nil, err
+	}
+	return lineAdapter{}.ParseData(data)
+}
+
+func (lineAdapter) ParseData(data []byte) (Configer, error) {
+	m := make(map[string]string)
+	for _, line := range strings.Split(string(data), "\n") {
+		line = strings.TrimSpace(line)
+		if line == "" {
+			continue
+		}
+		kv := strings.SplitN(line, "=", 2)
+		if len(kv) != 2 {
+			continue
+		}
+		m[strings.TrimSpace(kv[0])] = strings.TrimSpace(kv[1])
+	}
+	return newKV(m), nil
+}
+
+var registerLineAdapterOnce sync.Once
+
+func registerLineAdapter() {
+	registerLineAdapterOnce.Do(func() {
+		Register("line-test", lineAdapter{})
+	})
+}
+
+func TestParseWithIncludes(t *testing.T) {
+	registerLineAdapter()
+
+	dir, err := ioutil.TempDir("", "beego-config-include-test")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	write := func(name, content string) string {
+		p := filepath.Join(dir, name)
+		if err := ioutil.WriteFile(p, []byte(content), 0644); err != nil {
+			t.Fatal(err)
+		}
+		return p
+	}
+	write("db.conf", "dbhost=db.local\ndbport=3306\n")
+	write("log.conf", "loglevel=debug\n")
+	main := write("app.conf", "include=db.conf, log.conf\nhttpport=9090\ndbhost=override.local\n")
+
+	cnf, err := ParseWithIncludes("line-test", main)
+	if err != nil {
+		t.Fatalf("ParseWithIncludes: %v", err)
+	}
+
+	if v := cnf.String("httpport"); v != "9090" {
+		t.Errorf("httpport = %q, want 9090", v)
+	}
+	if v := cnf.String("loglevel"); v != "debug" {
+		t.Errorf("loglevel = %q, want debug (included from log.conf)", v)
+	}
+	if v := cnf.String("dbport"); v != "3306" {
+		t.Errorf("dbport = %q, want 3306 (included from db.conf)", v)
+	}
+	if v := cnf.String("dbhost"); v != "override.local" {
+		t.Errorf("dbhost = %q, want override.local (app.conf should win over its include)", v)
+	}
+}
+
+func TestParseWithIncludesCircular(t *testing.T) {
+	registerLineAdapter()
+
+	dir, err := ioutil.TempDir("", "beego-config-include-cycle-test")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	a := filepath.Join(dir, "a.conf")
+	b := filepath.Join(dir, "b.conf")
+	if err := ioutil.WriteFile(a, []byte("include=b.conf\n"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	if err := ioutil.WriteFile(b, []byte("include=a.conf\n"), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	_, err = ParseWithIncludes("line-test", a)
+	if err == nil {
+		t.Fatal("want circular include error")
+	}
+	if _, ok := err.(*ErrCircularInclude); !ok {
+		t.Errorf("want *ErrCircularInclude, got %T: %v", err, err)
+	}
+}
+
+func TestMergedConfigPrecedence(t *testing.T) {
+	base := newKV(map[string]string{"httpport": "8080", "runmode": "dev"})
+	override := newKV(map[string]string{"httpport": "9090"})
+
+	m, err := NewMergedConfig(base, override)
+	if err != nil {
+		t.Fatalf("NewMergedConfig: %v", err)
+	}
+
+	if v := m.String("httpport"); v != "9090" {
+		t.Errorf("httpport = %q, want 9090 (later source overrides earlier)", v)
+	}
+	if v := m.String("runmode"); v != "dev" {
+		t.Errorf("runmode = %q, want dev (only set in base)", v)
+	}
+
+	if err := m.Set("httpport", "7070"); err != nil {
+		t.Fatalf("Set: %v", err)
+	}
+	if v := override.String("httpport"); v != "7070" {
+		t.Errorf("Set should write to the topmost layer; override.httpport = %q, want 7070", v)
+	}
+}
+
+func TestNewMergedConfigRequiresSource(t *testing.T) {
+	if _, err := NewMergedConfig(); err == nil {
+		t.Fatal("want error when no sources are given")
+	}
+}
+
+func TestNewEnvConfig(t *testing.T) {
+	os.Setenv("BEEGOTEST_HTTPPORT", "9090")
+	os.Setenv("BEEGOTEST_MYSQL__HOST", "db.local")
+	defer os.Unsetenv("BEEGOTEST_HTTPPORT")
+	defer os.Unsetenv("BEEGOTEST_MYSQL__HOST")
+
+	e := NewEnvConfig("BEEGOTEST")
+	if v := e.String("httpport"); v != "9090" {
+		t.Errorf("httpport = %q, want 9090", v)
+	}
+	if v := e.String("mysql::host"); v != "db.local" {
+		t.Errorf("mysql::host = %q, want db.local (__ maps to section separator)", v)
+	}
+}
+
+func TestNewFlagConfig(t *testing.T) {
+	fs := flag.NewFlagSet("test", flag.ContinueOnError)
+	fs.String("httpport", "8080", "")
+	if err := fs.Parse([]string{"-httpport=9090"}); err != nil {
+		t.Fatal(err)
+	}
+
+	f := NewFlagConfig(fs)
+	if v := f.String("httpport"); v != "9090" {
+		t.Errorf("httpport = %q, want 9090", v)
+	}
+}
+
+// fakeRemoteProvider is a RemoteProvider used only by
+// TestRemoteConfigWatchDebounceAndClose.
+type fakeRemoteProvider struct {
+	mu     sync.Mutex
+	data   map[string]string
+	events chan ConfigEvent
+}
+
+func (p *fakeRemoteProvider) Fetch(endpoint, path string) (map[string]string, error) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	out := make(map[string]string, len(p.data))
+	for k, v := range p.data {
+		out[k] = v
+	}
+	return out, nil
+}
+
+func (p *fakeRemoteProvider) Watch(endpoint, path, key string, out chan<- ConfigEvent, stop <-chan struct{}) error {
+	for {
+		select {
+		case ev := <-p.events:
+			select {
+			case out <- ev:
+			case <-stop:
+				return nil
+			}
+		case <-stop:
+			return nil
+		}
+	}
+}
+
+func TestRemoteConfigWatchDebounceAndClose(t *testing.T) {
+	provider := &fakeRemoteProvider{
+		data:   map[string]string{"httpport": "8080"},
+		events: make(chan ConfigEvent, 4),
+	}
+	RegisterRemote("fake-watch-test", provider)
+
+	cnf, err := NewRemoteConfig("fake-watch-test", "ep", "path")
+	if err != nil {
+		t.Fatalf("NewRemoteConfig: %v", err)
+	}
+	rc, ok := cnf.(RemoteConfig)
+	if !ok {
+		t.Fatalf("NewRemoteConfig result %T does not implement RemoteConfig", cnf)
+	}
+
+	events, err := rc.Watch("httpport")
+	if err != nil {
+		t.Fatalf("Watch: %v", err)
+	}
+
+	provider.events <- ConfigEvent{Key: "httpport", Value: "9090"}
+
+	select {
+	case ev := <-events:
+		if ev.Value != "9090" {
+			t.Errorf("event value = %q, want 9090", ev.Value)
+		}
+	case <-time.After(2 * time.Second):
+		t.Fatal("timed out waiting for watch event")
+	}
+
+	if v := cnf.String("httpport"); v != "9090" {
+		t.Errorf("String(httpport) after watch event = %q, want 9090 (storage should be swapped before delivery)", v)
+	}
+
+	if err := rc.Close(); err != nil {
+		t.Fatalf("Close: %v", err)
+	}
+	// Close must be idempotent, and must not leave the debounce goroutine
+	// blocked forever trying to send on a channel nobody reads anymore.
+	if err := rc.Close(); err != nil {
+		t.Fatalf("second Close: %v", err)
+	}
+}